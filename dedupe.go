@@ -0,0 +1,130 @@
+package honeylager
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// DedupeSink wraps a lager.Sink, forwarding the first of a run of identical
+// (source, message, level) events immediately and collapsing the repeats
+// seen within the same window into a single further event carrying a
+// dedupe_count field. This is intended for noisy call sites (e.g. a
+// lager.Debug fired thousands of times a second by a busy component) where
+// per-event Honeycomb volume would otherwise be prohibitive.
+type DedupeSink struct {
+	inner  lager.Sink
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[dedupeKey]*dedupeEntry
+
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+type dedupeKey struct {
+	source   string
+	message  string
+	logLevel lager.LogLevel
+}
+
+type dedupeEntry struct {
+	logFormat lager.LogFormat
+	count     int
+}
+
+// NewDedupeSink returns a new DedupeSink wrapping inner. Callers are
+// expected to call Close() when they are done, to flush any entries
+// buffered for the in-flight window.
+func NewDedupeSink(inner lager.Sink, window time.Duration) *DedupeSink {
+	d := &DedupeSink{
+		inner:   inner,
+		window:  window,
+		entries: make(map[dedupeKey]*dedupeEntry),
+		ticker:  time.NewTicker(window),
+		done:    make(chan struct{}),
+	}
+
+	go d.run()
+
+	return d
+}
+
+// Log forwards the first occurrence of a (source, message, level) key to
+// inner immediately -- so a one-off ERROR/FATAL is never held back waiting
+// on window -- and buffers a count of any repeats seen before the next
+// flush, which are reported together as a single dedupe_count event. The
+// send for a first occurrence happens outside d.mu, so concurrent Log
+// calls for unrelated keys aren't serialized behind it.
+func (d *DedupeSink) Log(logFormat lager.LogFormat) {
+	key := dedupeKey{
+		source:   logFormat.Source,
+		message:  logFormat.Message,
+		logLevel: logFormat.LogLevel,
+	}
+
+	d.mu.Lock()
+
+	if entry, ok := d.entries[key]; ok {
+		entry.count++
+		d.mu.Unlock()
+		return
+	}
+
+	d.entries[key] = &dedupeEntry{logFormat: logFormat, count: 0}
+	d.mu.Unlock()
+
+	d.inner.Log(logFormat)
+}
+
+func (d *DedupeSink) run() {
+	for {
+		select {
+		case <-d.ticker.C:
+			d.flush()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// flush reports a single batched event for every key that saw repeats
+// since the last flush, carrying a dedupe_count of how many repeats were
+// collapsed. Keys with no repeats aren't reported again here: their first
+// (and only) occurrence was already forwarded immediately by Log.
+func (d *DedupeSink) flush() {
+	d.mu.Lock()
+	entries := d.entries
+	d.entries = make(map[dedupeKey]*dedupeEntry)
+	d.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.count == 0 {
+			continue
+		}
+
+		// Build a fresh Data map rather than mutating entry.logFormat.Data
+		// in place: that map is the same instance already handed to
+		// inner.Log for the first occurrence, which may still be in
+		// flight (e.g. a transport that queues for async sending).
+		logFormat := entry.logFormat
+		data := make(lager.Data, len(logFormat.Data)+1)
+		for k, v := range logFormat.Data {
+			data[k] = v
+		}
+		data["dedupe_count"] = entry.count
+		logFormat.Data = data
+
+		d.inner.Log(logFormat)
+	}
+}
+
+// Close stops the background flush ticker and flushes any entries buffered
+// for the in-flight window.
+func (d *DedupeSink) Close() {
+	d.ticker.Stop()
+	close(d.done)
+	d.flush()
+}
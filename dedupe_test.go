@@ -0,0 +1,160 @@
+package honeylager
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+func TestDedupeSink_Log_ForwardsFirstOccurrenceImmediately(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, time.Hour)
+	defer dedupe.Close()
+
+	dedupe.Log(lager.LogFormat{Source: "a", Message: "one-off-error", LogLevel: lager.ERROR})
+
+	waitForEvents(t, transport, 1, time.Second)
+
+	events := transport.Events()
+	if _, ok := events[0].Fields["dedupe_count"]; ok {
+		t.Errorf("first occurrence unexpectedly carries dedupe_count: %v", events[0].Fields)
+	}
+}
+
+func TestDedupeSink_Log_IsConcurrencySafe(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, 20*time.Millisecond)
+	defer dedupe.Close()
+
+	const n = 50
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func() {
+			dedupe.Log(lager.LogFormat{Source: "a", Message: "concurrent", LogLevel: lager.DEBUG})
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+
+	waitForEvents(t, transport, 2, time.Second)
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if _, ok := events[0].Fields["dedupe_count"]; ok {
+		t.Errorf("first event should be the immediately forwarded occurrence, got dedupe_count: %v", events[0].Fields)
+	}
+	if got := events[1].Fields["dedupe_count"]; got != n-1 {
+		t.Errorf("dedupe_count = %v, want %d", got, n-1)
+	}
+}
+
+func TestDedupeSink_CollapsesRepeatsWithinWindow(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, 20*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		dedupe.Log(lager.LogFormat{
+			Source:   "busy-component",
+			Message:  "some-action",
+			LogLevel: lager.DEBUG,
+		})
+	}
+
+	waitForEvents(t, transport, 2, time.Second)
+	dedupe.Close()
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if _, ok := events[0].Fields["dedupe_count"]; ok {
+		t.Errorf("immediately forwarded first occurrence unexpectedly carries dedupe_count: %v", events[0].Fields)
+	}
+	if got := events[1].Fields["dedupe_count"]; got != 4 {
+		t.Errorf("dedupe_count = %v, want 4 (the 4 repeats collapsed after the first occurrence)", got)
+	}
+}
+
+func TestDedupeSink_DistinctEventsAreNotCollapsed(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, 20*time.Millisecond)
+
+	dedupe.Log(lager.LogFormat{Source: "a", Message: "one", LogLevel: lager.DEBUG})
+	dedupe.Log(lager.LogFormat{Source: "b", Message: "two", LogLevel: lager.DEBUG})
+
+	waitForEvents(t, transport, 2, time.Second)
+	dedupe.Close()
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for _, e := range events {
+		if _, ok := e.Fields["dedupe_count"]; ok {
+			t.Errorf("unrepeated event unexpectedly carries dedupe_count: %v", e.Fields)
+		}
+	}
+}
+
+func TestDedupeSink_Close_FlushesRemainingEntries(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, time.Hour)
+	dedupe.Log(lager.LogFormat{Message: "buffered", LogLevel: lager.DEBUG})
+	dedupe.Log(lager.LogFormat{Message: "buffered", LogLevel: lager.DEBUG})
+	dedupe.Close()
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if got := events[1].Fields["dedupe_count"]; got != 1 {
+		t.Errorf("dedupe_count = %v, want 1", got)
+	}
+}
+
+func TestDedupeSink_Flush_DoesNotMutateFirstOccurrencesData(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	dedupe := NewDedupeSink(sink, 20*time.Millisecond)
+
+	data := lager.Data{"key": "value"}
+	dedupe.Log(lager.LogFormat{Source: "a", Message: "one", LogLevel: lager.DEBUG, Data: data})
+	dedupe.Log(lager.LogFormat{Source: "a", Message: "one", LogLevel: lager.DEBUG, Data: data})
+
+	waitForEvents(t, transport, 2, time.Second)
+	dedupe.Close()
+
+	if _, ok := data["dedupe_count"]; ok {
+		t.Error("flush mutated the Data map shared with the already-sent first occurrence")
+	}
+}
+
+func waitForEvents(t *testing.T, transport *MemoryTransport, n int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if len(transport.Events()) >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for %d events, got %d", n, len(transport.Events()))
+}
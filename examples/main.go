@@ -24,7 +24,6 @@ func main() {
 
 	l := lager.NewLogger("my-component")
 	l.RegisterSink(sink)
-	go honeylager.ReadResponses()
 
 	l.Info("example-starting")
 	for i := 0; i < 10; i++ {
@@ -45,5 +44,5 @@ func main() {
 	l.Info("example-complete")
 
 	time.Sleep(500 * time.Millisecond)
-	fmt.Println("complete")
+	fmt.Printf("complete, stats: %+v\n", sink.Stats())
 }
@@ -4,61 +4,142 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/lager"
-	libhoney "github.com/honeycombio/libhoney-go"
 )
 
+// honeylagerPackagePrefix and lagerDispatchPrefix are used by callerFunction
+// to walk past frames internal to honeylager -- Log itself, plus whichever
+// of LogWithContext/TraceSink.Log/NewRootSpan's closure forwarded the call
+// to it -- and past any code.cloudfoundry.org/lager dispatch frames
+// (Logger.Info/Debug/Error and whatever private helpers they route
+// through), so that "function" reports the actual call site regardless of
+// how many of those hops sit between it and Log.
 const (
-	metadataKeyID = "id"
+	honeylagerPackagePrefix = "github.com/robdimsdale/honeylager."
+	lagerDispatchPrefix     = "code.cloudfoundry.org/lager."
 )
 
+// defaultMaxRetries is the number of times NewSink and NewSinkWithTransport
+// retry a retryable send failure before dropping the event. Use
+// NewSinkWithResponseProcessor to configure this.
+const defaultMaxRetries = 3
+
 type Sink struct {
 	minLogLevel lager.LogLevel
-	builder     *libhoney.Builder
+	transport   Transport
+	extraFields map[string]interface{}
+	sampleRate  uint
+	sampler     Sampler
+	processor   *ResponseProcessor
 }
 
-// NewSink returns a new Sink
+// NewSink returns a new Sink that sends events to Honeycomb.
 // Callers are expected to call Close() when they are done
 // e.g. sink := NewSink(); defer sink.Close()
-// Callers may also wish to track the responses with ReadResponses()
 func NewSink(
 	honeycombWriteKey string,
 	honeycombDataset string,
 	minLogLevel lager.LogLevel,
 ) *Sink {
-	b := libhoney.NewBuilder()
-	b.WriteKey = honeycombWriteKey
-	b.Dataset = honeycombDataset
-
-	b.AddDynamicField(
-		"num_goroutines",
-		func() interface{} {
-			return runtime.NumGoroutine()
-		},
+	return NewSinkWithTransport(
+		newLibhoneyTransport(honeycombWriteKey, honeycombDataset),
+		minLogLevel,
 	)
+}
 
-	b.AddDynamicField(
-		"memory_allocation",
-		func() interface{} {
-			var mem runtime.MemStats
-			runtime.ReadMemStats(&mem)
-			return mem.Alloc
-		},
-	)
+// NewSinkWithTransport returns a new Sink that sends events via the given
+// Transport, rather than the default libhoney-backed one, retrying
+// retryable failures up to defaultMaxRetries times. This is what
+// NewStdoutSink and NewMemorySink are built on, and allows callers with
+// their own destination to plug it in directly.
+func NewSinkWithTransport(transport Transport, minLogLevel lager.LogLevel) *Sink {
+	return NewSinkWithResponseProcessor(transport, minLogLevel, defaultMaxRetries, nil)
+}
+
+// NewSinkWithResponseProcessor returns a new Sink whose ResponseProcessor is
+// configured with maxRetries and onStats, for callers who need tighter
+// control over retry behaviour or want to export Stats (e.g. to
+// Prometheus) as they change.
+func NewSinkWithResponseProcessor(
+	transport Transport,
+	minLogLevel lager.LogLevel,
+	maxRetries int,
+	onStats func(Stats),
+) *Sink {
+	processor := NewResponseProcessor(transport, maxRetries, onStats)
+	processor.Start()
 
 	return &Sink{
 		minLogLevel: minLogLevel,
-		builder:     b,
+		transport:   transport,
+		processor:   processor,
 	}
 }
 
+// Close cancels any retries still waiting on their backoff, shuts down the
+// underlying Transport and drains the Responses it still has pending.
 func (sink *Sink) Close() {
-	libhoney.Close()
+	if err := sink.processor.Close(); err != nil {
+		printError(err)
+	}
+}
+
+// Stats returns a snapshot of sink's ResponseProcessor counters.
+func (sink *Sink) Stats() Stats {
+	return sink.processor.Stats()
+}
+
+// With returns a new Sink sharing the same underlying Transport as sink, but
+// with fields added to every event logged through it. This mirrors the
+// pattern of a contextual logger (e.g. `log.New("hash", tx.Hash())`),
+// letting callers scope a Sink to e.g. a single request without having to
+// thread the fields through every lager.Data call site:
+//
+//	reqSink := sink.With(map[string]interface{}{"request_id": id})
+//	l.RegisterSink(reqSink)
+func (sink *Sink) With(fields map[string]interface{}) *Sink {
+	merged := make(map[string]interface{}, len(sink.extraFields)+len(fields))
+	for k, v := range sink.extraFields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &Sink{
+		minLogLevel: sink.minLogLevel,
+		transport:   sink.transport,
+		extraFields: merged,
+		sampleRate:  sink.sampleRate,
+		sampler:     sink.sampler,
+		processor:   sink.processor,
+	}
+}
+
+// WithSource is a convenience wrapper around With that tags every event
+// logged through the returned Sink with a "source" field.
+func (sink *Sink) WithSource(source string) *Sink {
+	return sink.With(map[string]interface{}{"source": source})
+}
+
+// WithSampling returns a new Sink sharing the same Transport and fields as
+// sink, but which only forwards events kept by sampler. Kept events carry a
+// SampleRate field so Honeycomb can extrapolate true event counts from the
+// sampled ones it receives.
+func (sink *Sink) WithSampling(rate uint, sampler Sampler) *Sink {
+	return &Sink{
+		minLogLevel: sink.minLogLevel,
+		transport:   sink.transport,
+		extraFields: sink.extraFields,
+		sampleRate:  rate,
+		sampler:     sampler,
+		processor:   sink.processor,
+	}
 }
 
 func (sink *Sink) Log(logFormat lager.LogFormat) {
@@ -66,51 +147,95 @@ func (sink *Sink) Log(logFormat lager.LogFormat) {
 		return
 	}
 
-	ev := sink.builder.NewEvent()
+	if sink.sampler != nil && !sink.sampler(logFormat) {
+		return
+	}
 
-	// 0 is current function
-	// 1 is lager.Info, lager.Debug etc
-	// 2 is the function that called lager.Info, lager.Debug etc
-	functionOffset := 2
-	if pc, _, _, ok := runtime.Caller(functionOffset); ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		ev.AddField("function", funcName)
+	fields := map[string]interface{}{
+		"num_goroutines": runtime.NumGoroutine(),
 	}
 
-	ev.Metadata = map[string]interface{}{
-		metadataKeyID: rand.Intn(math.MaxInt32),
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fields["memory_allocation"] = mem.Alloc
+
+	if funcName := callerFunction(); funcName != "" {
+		fields["function"] = funcName
 	}
-	ev.AddField("lager_source", logFormat.Source)
-	ev.AddField("lager_message", logFormat.Message)
-	ev.AddField("lager_log_level_iota", logFormat.LogLevel)
-	ev.AddField("lager_log_level", logLevelToString(logFormat.LogLevel))
+
+	fields["lager_source"] = logFormat.Source
+	fields["lager_message"] = logFormat.Message
+	fields["lager_log_level_iota"] = logFormat.LogLevel
+	fields["lager_log_level"] = logLevelToString(logFormat.LogLevel)
 
 	// namespace the 'session' value becauase it isn't particularly useful for
 	// event-based observability, and therefore namespacing it makes it easier to
 	// reason about (and ignore).
 	if session, ok := logFormat.Data["session"]; ok {
-		ev.AddField("lager_session", session)
+		fields["lager_session"] = session
 		delete(logFormat.Data, "session")
 	}
 
-	ev.Add(logFormat.Data)
+	for k, v := range logFormat.Data {
+		fields[k] = v
+	}
+
+	for k, v := range sink.extraFields {
+		fields[k] = v
+	}
+
+	ev := Event{
+		Fields:     fields,
+		SampleRate: sink.sampleRate,
+	}
 
 	// Override the event timestamp if the JSON blob has a valid time. If time
 	// is missing or it doesn't parse correctly, the event will be sent with the
 	// default time (Now())
 	ts, err := parseLagerTimestamp(logFormat.Timestamp)
 	if err != nil {
-		ev.AddField("lager_timestamp_parse_error", err)
+		fields["lager_timestamp_parse_error"] = err.Error()
 	} else {
 		ev.Timestamp = ts
 	}
 
-	err = ev.Send()
-	if err != nil {
+	ev.Metadata = &eventEnvelope{id: rand.Intn(math.MaxInt32), event: ev}
+
+	if err := sink.transport.Send(ev); err != nil {
 		printError(err)
 	}
 }
 
+// callerFunction walks the call stack leading into Log, skipping every
+// frame internal to honeylager and to code.cloudfoundry.org/lager's
+// dispatch machinery, and returns the name of the first frame past those
+// -- the actual site that triggered this log entry. It returns "" if the
+// stack couldn't be walked.
+func callerFunction() string {
+	var pcs [64]uintptr
+
+	n := runtime.Callers(2, pcs[:])
+	if n == 0 {
+		return ""
+	}
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		isInternal := strings.HasPrefix(frame.Function, honeylagerPackagePrefix) ||
+			strings.HasPrefix(frame.Function, lagerDispatchPrefix)
+		if !isInternal {
+			return frame.Function
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
+
 func parseLagerTimestamp(ts string) (time.Time, error) {
 	// Example: "1504804895.094333887"
 
@@ -140,42 +265,6 @@ func logLevelToString(logLevel lager.LogLevel) string {
 	}
 }
 
-// ReadResponses is a blocking method that waits for responses from Honeycomb
-// and prints whether the event emission succeeded or failed.
-// Callers will likely want to execute this method in a goroutine due to its
-// blocking, asynchronous, nature.
-func ReadResponses() {
-	for r := range libhoney.Responses() {
-		if r.StatusCode < http.StatusOK || r.StatusCode >= http.StatusMultipleChoices {
-			printError(fmt.Errorf(
-				"bad status code: '%d', err: '%v', response body: '%s'",
-				r.StatusCode, r.Err, r.Body,
-			))
-			continue
-		}
-
-		if r.Metadata == nil {
-			printError(fmt.Errorf("metadata was nil"))
-			continue
-		}
-
-		metadataMap, ok := r.Metadata.(map[string]interface{})
-		if !ok {
-			printError(fmt.Errorf(
-				"metadata was not expected type map[string]interface{}, metadata: %+v",
-				r.Metadata,
-			))
-			continue
-		}
-
-		fmt.Printf(
-			"Successfully sent event %v to Honeycomb in %v\n",
-			metadataMap[metadataKeyID],
-			r.Duration,
-		)
-	}
-}
-
 func printError(err error) {
 	fmt.Printf("honeylager error: %v\n", err)
 }
@@ -0,0 +1,46 @@
+package honeylager_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+
+	"github.com/robdimsdale/honeylager"
+)
+
+// TestSink_Log_FunctionFieldReportsRealCallSite lives in the external
+// honeylager_test package (rather than alongside the repo's usual
+// internal-package tests) because it's the only way to meaningfully
+// assert on the exact value of the "function" field: callerFunction
+// identifies honeylager-internal frames by package path, so a test in
+// package honeylager would itself be mistaken for one of those frames.
+// Every real caller is, by construction, in a different package than
+// honeylager -- which is exactly what this test simulates.
+func TestSink_Log_FunctionFieldReportsRealCallSite(t *testing.T) {
+	sink, transport := honeylager.NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	l := lager.NewLogger("test-component")
+	l.RegisterSink(sink)
+	l.Debug("direct")
+
+	l = lager.NewLogger("test-component")
+	l.RegisterSink(sink.NewTraceSink(context.Background()))
+	l.Debug("via-tracesink")
+
+	sink.LogWithContext(context.Background(), lager.LogFormat{Message: "via-ctx", LogLevel: lager.DEBUG})
+
+	events := transport.Events()
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	for _, e := range events {
+		fn, _ := e.Fields["function"].(string)
+		if !strings.Contains(fn, "TestSink_Log_FunctionFieldReportsRealCallSite") {
+			t.Errorf("function = %q, want it to contain TestSink_Log_FunctionFieldReportsRealCallSite", fn)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package honeylager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// TestSink_Log_FunctionFieldDoesNotLeakInternalFrames guards against the
+// "function" field reporting an honeylager-internal symbol (e.g.
+// "(*TraceSink).Log" or "LogWithContext") instead of the real call site
+// when logging is routed through LogWithContext/TraceSink rather than
+// calling Log directly off a lager.Logger dispatch. It can't assert the
+// exact caller name: this test itself lives in package honeylager, so
+// callerFunction's package-based skip treats its own frame the same as
+// any other honeylager-internal one (real callers, being external
+// packages, don't have this ambiguity). What it can and does assert is
+// that the reported function never leaks the name of the honeylager
+// plumbing it was routed through.
+func TestSink_Log_FunctionFieldDoesNotLeakInternalFrames(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	l := lager.NewLogger("test-component")
+	l.RegisterSink(sink.NewTraceSink(context.Background()))
+	l.Debug("via-tracesink")
+
+	viaTraceSink := functionField(t, transport, 0)
+	if strings.Contains(viaTraceSink, "TraceSink") {
+		t.Fatalf("function leaked an internal frame: %q", viaTraceSink)
+	}
+
+	sink.LogWithContext(context.Background(), lager.LogFormat{Message: "via-ctx", LogLevel: lager.DEBUG})
+
+	viaLogWithContext := functionField(t, transport, 1)
+	if strings.Contains(viaLogWithContext, "LogWithContext") {
+		t.Fatalf("function leaked an internal frame: %q", viaLogWithContext)
+	}
+}
+
+func functionField(t *testing.T, transport *MemoryTransport, index int) string {
+	t.Helper()
+
+	events := transport.Events()
+	if len(events) <= index {
+		t.Fatalf("got %d events, want more than %d", len(events), index)
+	}
+
+	fn, _ := events[index].Fields["function"].(string)
+	return fn
+}
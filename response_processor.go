@@ -0,0 +1,206 @@
+package honeylager
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventEnvelope is stashed in an Event's Metadata by Sink.Log, so that a
+// ResponseProcessor can re-send the original Event on a retryable failure,
+// and correlate a Response back to the attempt that produced it.
+type eventEnvelope struct {
+	id      int
+	event   Event
+	attempt int
+}
+
+// StatusCodeCounts maps an HTTP status code to the number of responses seen
+// with that code.
+type StatusCodeCounts map[int]uint64
+
+// Stats is a point-in-time snapshot of a ResponseProcessor's counters.
+type Stats struct {
+	EventsSent    uint64
+	EventsDropped uint64
+	EventsRetried uint64
+	StatusCodes   StatusCodeCounts
+}
+
+// ResponseProcessor consumes Responses from a Transport. Retryable failures
+// (5xx, 429, and network errors) are re-sent with exponential backoff and
+// jitter up to maxRetries times; terminal failures (4xx, and retryable
+// failures that have exhausted their retries) are dropped. EventsSent,
+// EventsDropped, EventsRetried and a per-status-code histogram are
+// available via Stats, and optionally pushed to a user-supplied callback
+// as they change, e.g. for export to Prometheus.
+type ResponseProcessor struct {
+	transport  Transport
+	maxRetries int
+	onStats    func(Stats)
+
+	eventsSent    uint64
+	eventsDropped uint64
+	eventsRetried uint64
+
+	mu          sync.Mutex
+	statusCodes StatusCodeCounts
+
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// NewResponseProcessor returns a new ResponseProcessor for transport.
+// maxRetries is the number of times a retryable failure is re-sent before
+// being dropped. onStats may be nil.
+func NewResponseProcessor(transport Transport, maxRetries int, onStats func(Stats)) *ResponseProcessor {
+	return &ResponseProcessor{
+		transport:   transport,
+		maxRetries:  maxRetries,
+		onStats:     onStats,
+		statusCodes: make(StatusCodeCounts),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start begins processing transport's Responses in a managed goroutine.
+// Start must only be called once.
+func (p *ResponseProcessor) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run()
+	}()
+}
+
+// run drains transport's Responses() until that channel is closed. It
+// deliberately does not select on p.done: racing a cancellation signal
+// against the data channel would let Close() return while a Transport's
+// internal forwarder goroutine is still blocked trying to send a response
+// nobody will read again. Close() instead closes the Transport first, which
+// is expected to close the Responses() channel and let run() drain
+// whatever's still in flight before returning on its own.
+func (p *ResponseProcessor) run() {
+	for r := range p.transport.Responses() {
+		p.handle(r)
+	}
+}
+
+func (p *ResponseProcessor) handle(r Response) {
+	p.recordStatusCode(r.StatusCode)
+
+	if isSuccess(r) {
+		atomic.AddUint64(&p.eventsSent, 1)
+		p.reportStats()
+		return
+	}
+
+	env, ok := r.Metadata.(*eventEnvelope)
+	if !ok || !isRetryable(r) || env.attempt >= p.maxRetries {
+		atomic.AddUint64(&p.eventsDropped, 1)
+		p.reportStats()
+
+		if ok {
+			printError(retryError(env, r))
+		}
+		return
+	}
+
+	atomic.AddUint64(&p.eventsRetried, 1)
+	p.reportStats()
+
+	env.attempt++
+	p.retryAfter(retryBackoff(env.attempt), env)
+}
+
+func (p *ResponseProcessor) retryAfter(delay time.Duration, env *eventEnvelope) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		select {
+		case <-time.After(delay):
+		case <-p.done:
+			return
+		}
+
+		ev := env.event
+		ev.Metadata = env
+		if err := p.transport.Send(ev); err != nil {
+			printError(err)
+		}
+	}()
+}
+
+func (p *ResponseProcessor) recordStatusCode(code int) {
+	p.mu.Lock()
+	p.statusCodes[code]++
+	p.mu.Unlock()
+}
+
+func (p *ResponseProcessor) reportStats() {
+	if p.onStats == nil {
+		return
+	}
+	p.onStats(p.Stats())
+}
+
+// Stats returns a snapshot of the processor's counters.
+func (p *ResponseProcessor) Stats() Stats {
+	p.mu.Lock()
+	statusCodes := make(StatusCodeCounts, len(p.statusCodes))
+	for code, count := range p.statusCodes {
+		statusCodes[code] = count
+	}
+	p.mu.Unlock()
+
+	return Stats{
+		EventsSent:    atomic.LoadUint64(&p.eventsSent),
+		EventsDropped: atomic.LoadUint64(&p.eventsDropped),
+		EventsRetried: atomic.LoadUint64(&p.eventsRetried),
+		StatusCodes:   statusCodes,
+	}
+}
+
+// Close cancels any in-flight retry backoffs, closes the underlying
+// Transport -- which is expected to close its Responses() channel and let
+// run() drain whatever's still pending -- and waits for both to finish
+// before returning.
+func (p *ResponseProcessor) Close() error {
+	close(p.done)
+	err := p.transport.Close()
+	p.wg.Wait()
+
+	return err
+}
+
+func isSuccess(r Response) bool {
+	return r.Err == nil && r.StatusCode >= http.StatusOK && r.StatusCode < http.StatusMultipleChoices
+}
+
+func isRetryable(r Response) bool {
+	if r.Err != nil {
+		return true
+	}
+
+	return r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= http.StatusInternalServerError
+}
+
+// retryBackoff returns an exponential backoff with full jitter for the
+// given (1-indexed) attempt number, e.g. attempt 1 is in [0ms, 200ms),
+// attempt 2 is in [0ms, 400ms), and so on.
+func retryBackoff(attempt int) time.Duration {
+	base := time.Duration(math.Pow(2, float64(attempt))) * 100 * time.Millisecond
+	return time.Duration(rand.Int63n(int64(base)))
+}
+
+func retryError(env *eventEnvelope, r Response) error {
+	return fmt.Errorf(
+		"event %d dropped after %d attempt(s): status code '%d', err: '%v'",
+		env.id, env.attempt+1, r.StatusCode, r.Err,
+	)
+}
@@ -0,0 +1,188 @@
+package honeylager
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// delayedResponseTransport sends a successful Response shortly after every
+// Send, on its own goroutine -- mirroring a real network-backed Transport
+// where the response races the caller's shutdown.
+type delayedResponseTransport struct {
+	mu        sync.Mutex
+	responses chan Response
+	closed    bool
+}
+
+func newDelayedResponseTransport() *delayedResponseTransport {
+	return &delayedResponseTransport{responses: make(chan Response)}
+}
+
+func (t *delayedResponseTransport) Send(e Event) error {
+	go func() {
+		time.Sleep(time.Millisecond)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.closed {
+			return
+		}
+		t.responses <- Response{Metadata: e.Metadata, StatusCode: 200}
+	}()
+
+	return nil
+}
+
+func (t *delayedResponseTransport) Responses() <-chan Response {
+	return t.responses
+}
+
+func (t *delayedResponseTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	close(t.responses)
+	return nil
+}
+
+// TestSink_Close_DoesNotLeakGoroutines reproduces the scenario from the
+// chunk0-6 review: a Transport that emits a Response shortly after Send
+// used to race ResponseProcessor.run()'s shutdown, leaving the Transport's
+// response-sending goroutine permanently blocked. Close() is now expected
+// to drain pending responses rather than abandon them.
+func TestSink_Close_DoesNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 200; i++ {
+		sink := NewSinkWithTransport(newDelayedResponseTransport(), lager.DEBUG)
+		sink.Log(lager.LogFormat{Message: "some-action", LogLevel: lager.DEBUG})
+		sink.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before+5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Errorf("goroutine leak: started with %d, ended with %d", before, after)
+	}
+}
+
+func TestResponseProcessor_RetriesRetryableFailures(t *testing.T) {
+	transport := newStubTransport()
+	processor := NewResponseProcessor(transport, 2, nil)
+	processor.Start()
+
+	_ = transport.Send(Event{Fields: map[string]interface{}{"lager_message": "boom"}})
+	transport.respondToLast(Response{StatusCode: 503})
+	transport.waitForSends(t, 2, time.Second)
+	transport.respondToLast(Response{StatusCode: 200})
+
+	if err := processor.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	stats := processor.Stats()
+	if stats.EventsSent != 1 {
+		t.Errorf("EventsSent = %d, want 1", stats.EventsSent)
+	}
+	if stats.EventsRetried != 1 {
+		t.Errorf("EventsRetried = %d, want 1", stats.EventsRetried)
+	}
+}
+
+func TestResponseProcessor_DropsTerminalFailures(t *testing.T) {
+	transport := newStubTransport()
+	processor := NewResponseProcessor(transport, 2, nil)
+	processor.Start()
+
+	_ = transport.Send(Event{Fields: map[string]interface{}{"lager_message": "bad-request"}})
+	transport.respondToLast(Response{StatusCode: 400})
+
+	deadline := time.Now().Add(time.Second)
+	for processor.Stats().EventsDropped == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := processor.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	stats := processor.Stats()
+	if stats.EventsDropped != 1 {
+		t.Errorf("EventsDropped = %d, want 1", stats.EventsDropped)
+	}
+	if stats.EventsRetried != 0 {
+		t.Errorf("EventsRetried = %d, want 0", stats.EventsRetried)
+	}
+}
+
+// stubTransport is a Transport a test can drive by hand: Send just records
+// the Event, and the test decides when (and with what) to respond.
+type stubTransport struct {
+	mu        sync.Mutex
+	sent      []Event
+	responses chan Response
+}
+
+func newStubTransport() *stubTransport {
+	return &stubTransport{responses: make(chan Response, 16)}
+}
+
+// Send records e, auto-wrapping Metadata in an eventEnvelope if the caller
+// (as in these tests, calling Send directly rather than via Sink.Log)
+// hasn't already set one -- ResponseProcessor needs it to decide whether a
+// failure is retryable.
+func (t *stubTransport) Send(e Event) error {
+	if e.Metadata == nil {
+		e.Metadata = &eventEnvelope{event: e}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, e)
+
+	return nil
+}
+
+func (t *stubTransport) respondToLast(r Response) {
+	t.mu.Lock()
+	last := t.sent[len(t.sent)-1]
+	t.mu.Unlock()
+
+	r.Metadata = last.Metadata
+	t.responses <- r
+}
+
+func (t *stubTransport) waitForSends(t2 *testing.T, n int, timeout time.Duration) {
+	t2.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		t.mu.Lock()
+		count := len(t.sent)
+		t.mu.Unlock()
+
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t2.Fatalf("timed out waiting for %d sends", n)
+}
+
+func (t *stubTransport) Responses() <-chan Response {
+	return t.responses
+}
+
+func (t *stubTransport) Close() error {
+	close(t.responses)
+	return nil
+}
@@ -0,0 +1,43 @@
+package honeylager
+
+import (
+	"hash/fnv"
+	"math/rand"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Sampler decides whether a given log event should be kept. It is called
+// once per event logged through a Sink configured via WithSampling; events
+// for which it returns false are dropped before ever reaching the
+// Transport.
+type Sampler func(lager.LogFormat) bool
+
+// NewUniformSampler returns a Sampler that independently keeps events by
+// head-based random sampling at a rate of 1-in-rate.
+func NewUniformSampler(rate uint) Sampler {
+	if rate <= 1 {
+		return func(lager.LogFormat) bool { return true }
+	}
+
+	return func(lager.LogFormat) bool {
+		return rand.Intn(int(rate)) == 0
+	}
+}
+
+// NewKeySampler returns a Sampler that deterministically keeps events at a
+// rate of 1-in-rate, keyed on the result of keyFunc. Every event with the
+// same key is either always kept or always dropped, which keeps related
+// events (e.g. everything sharing a lager_source) together instead of being
+// independently coin-flipped.
+func NewKeySampler(rate uint, keyFunc func(lager.LogFormat) string) Sampler {
+	if rate <= 1 {
+		return func(lager.LogFormat) bool { return true }
+	}
+
+	return func(logFormat lager.LogFormat) bool {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(keyFunc(logFormat)))
+		return h.Sum32()%uint32(rate) == 0
+	}
+}
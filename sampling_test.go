@@ -0,0 +1,69 @@
+package honeylager
+
+import (
+	"fmt"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+)
+
+func TestNewUniformSampler_RateOneKeepsEverything(t *testing.T) {
+	sampler := NewUniformSampler(1)
+
+	for i := 0; i < 100; i++ {
+		if !sampler(lager.LogFormat{}) {
+			t.Fatal("rate-1 sampler dropped an event")
+		}
+	}
+}
+
+func TestNewKeySampler_SameKeyIsDeterministic(t *testing.T) {
+	sampler := NewKeySampler(10, func(lf lager.LogFormat) string { return lf.Source })
+
+	logFormat := lager.LogFormat{Source: "noisy-component"}
+	first := sampler(logFormat)
+
+	for i := 0; i < 50; i++ {
+		if sampler(logFormat) != first {
+			t.Fatalf("same key produced a different sampling decision on attempt %d", i)
+		}
+	}
+}
+
+func TestNewKeySampler_DifferentKeysCanDiffer(t *testing.T) {
+	sampler := NewKeySampler(2, func(lf lager.LogFormat) string { return lf.Source })
+
+	kept := 0
+	for i := 0; i < 20; i++ {
+		if sampler(lager.LogFormat{Source: fmt.Sprintf("component-%d", i)}) {
+			kept++
+		}
+	}
+
+	if kept == 0 || kept == 20 {
+		t.Fatalf("expected a mix of kept/dropped keys at rate 2, got %d/20 kept", kept)
+	}
+}
+
+func TestSink_WithSampling_DropsUnsampledEvents(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	neverSampled := sink.WithSampling(100, func(lager.LogFormat) bool { return false })
+	neverSampled.Log(lager.LogFormat{Message: "should-be-dropped", LogLevel: lager.DEBUG})
+
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want 0", len(events))
+	}
+
+	alwaysSampled := sink.WithSampling(100, func(lager.LogFormat) bool { return true })
+	alwaysSampled.Log(lager.LogFormat{Message: "should-be-kept", LogLevel: lager.DEBUG})
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if got := events[0].SampleRate; got != 100 {
+		t.Errorf("SampleRate = %d, want 100", got)
+	}
+}
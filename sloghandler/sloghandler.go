@@ -0,0 +1,144 @@
+// Package sloghandler provides a slog.Handler that forwards log records to
+// Honeycomb through a honeylager.Sink. It lets callers who have moved off
+// code.cloudfoundry.org/lager onto the standard library's log/slog keep
+// sending their logs to Honeycomb, with the same pluggable transport,
+// sampling, retries/Stats and tracing that honeylager.Sink has.
+package sloghandler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+
+	"github.com/robdimsdale/honeylager"
+)
+
+// Handler is a slog.Handler that forwards records to Honeycomb via a
+// honeylager.Sink.
+type Handler struct {
+	sink     *honeylager.Sink
+	minLevel slog.Level
+	groups   []string
+}
+
+// New returns a new Handler that logs through sink. Use honeylager.NewSink
+// (or NewStdoutSink/NewMemorySink, for tests) to construct sink.
+// Callers are expected to call Close() when they are done, e.g.
+//
+//	sink := honeylager.NewSink(writeKey, dataset, lager.DEBUG)
+//	h := sloghandler.New(sink, slog.LevelDebug)
+//	defer h.Close()
+func New(sink *honeylager.Sink, minLevel slog.Level) *Handler {
+	return &Handler{
+		sink:     sink,
+		minLevel: minLevel,
+	}
+}
+
+// Close shuts down the underlying honeylager.Sink.
+func (h *Handler) Close() {
+	h.sink.Close()
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.minLevel
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	data := lager.Data{
+		"slog_level": r.Level.String(),
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(data, h.groups, a)
+		return true
+	})
+
+	h.sink.LogWithContext(ctx, lager.LogFormat{
+		Source:    "slog",
+		Message:   r.Message,
+		LogLevel:  toLagerLevel(r.Level),
+		Timestamp: formatTimestamp(r.Time),
+		Data:      data,
+	})
+
+	return nil
+}
+
+// WithAttrs implements slog.Handler. The attrs are added to the underlying
+// Sink (via Sink.With) so they are attached to every event subsequently
+// logged through the returned Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := map[string]interface{}{}
+	for _, a := range attrs {
+		addAttr(fields, h.groups, a)
+	}
+
+	return &Handler{
+		sink:     h.sink.With(fields),
+		minLevel: h.minLevel,
+		groups:   h.groups,
+	}
+}
+
+// WithGroup implements slog.Handler. Subsequent attrs (whether passed to
+// Handle or WithAttrs) are namespaced with a dotted prefix of all the
+// groups entered so far.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	groups := make([]string, len(h.groups)+1)
+	copy(groups, h.groups)
+	groups[len(h.groups)] = name
+
+	return &Handler{
+		sink:     h.sink,
+		minLevel: h.minLevel,
+		groups:   groups,
+	}
+}
+
+// addAttr flattens a into fields, dotted-prefixing its key with groups. A
+// group-kind attr (whether from WithGroup or an inline slog.Group(...)
+// passed directly to a log call) recurses into its own attrs with its key
+// appended to the prefix, rather than storing the raw []slog.Attr value.
+func addAttr(fields map[string]interface{}, groups []string, a slog.Attr) {
+	v := a.Value.Resolve()
+
+	if v.Kind() == slog.KindGroup {
+		nested := append(append([]string{}, groups...), a.Key)
+		for _, ga := range v.Group() {
+			addAttr(fields, nested, ga)
+		}
+		return
+	}
+
+	fields[prefixedKey(groups, a.Key)] = v.Any()
+}
+
+func prefixedKey(groups []string, key string) string {
+	if len(groups) == 0 {
+		return key
+	}
+
+	return fmt.Sprintf("%s.%s", strings.Join(groups, "."), key)
+}
+
+func toLagerLevel(level slog.Level) lager.LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return lager.DEBUG
+	case level < slog.LevelError:
+		return lager.INFO
+	default:
+		return lager.ERROR
+	}
+}
+
+func formatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%.9f", float64(t.UnixNano())/1e9)
+}
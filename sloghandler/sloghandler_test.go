@@ -0,0 +1,162 @@
+package sloghandler
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+
+	"github.com/robdimsdale/honeylager"
+)
+
+func newTestHandler(t *testing.T) (*Handler, *honeylager.MemoryTransport) {
+	t.Helper()
+
+	sink, transport := honeylager.NewMemorySink(lager.DEBUG)
+	t.Cleanup(sink.Close)
+
+	return New(sink, slog.LevelDebug), transport
+}
+
+func TestHandler_Handle_FlattensAttrs(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h)
+
+	logger.Info("request handled", "request_id", "abc-123", "status", 200)
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if got := events[0].Fields["request_id"]; got != "abc-123" {
+		t.Errorf("request_id = %v, want abc-123", got)
+	}
+	if got := events[0].Fields["status"]; got != int64(200) {
+		t.Errorf("status = %v, want 200", got)
+	}
+	if got := events[0].Fields["lager_message"]; got != "request handled" {
+		t.Errorf("lager_message = %v, want %q", got, "request handled")
+	}
+}
+
+func TestHandler_Handle_FlattensInlineGroup(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h)
+
+	logger.Info("request handled", slog.Group("req", slog.String("id", "abc-123"), slog.Int("size", 5)))
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	fields := events[0].Fields
+	if got := fields["req.id"]; got != "abc-123" {
+		t.Errorf("req.id = %v, want abc-123", got)
+	}
+	if got := fields["req.size"]; got != int64(5) {
+		t.Errorf("req.size = %v, want 5", got)
+	}
+	if _, ok := fields["req"]; ok {
+		t.Errorf("got a raw %q field, want the group's attrs flattened instead: %v", "req", fields)
+	}
+}
+
+func TestHandler_Handle_FlattensNestedInlineGroup(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h)
+
+	logger.Info("nested", slog.Group("outer", slog.Group("inner", slog.String("id", "xyz"))))
+
+	events := transport.Events()
+	fields := events[0].Fields
+	if got := fields["outer.inner.id"]; got != "xyz" {
+		t.Errorf("outer.inner.id = %v, want xyz", got)
+	}
+	if _, ok := fields["outer"]; ok {
+		t.Errorf("got a raw %q field, want it fully flattened: %v", "outer", fields)
+	}
+	if _, ok := fields["outer.inner"]; ok {
+		t.Errorf("got a raw %q field, want it fully flattened: %v", "outer.inner", fields)
+	}
+}
+
+func TestHandler_WithGroup_NamespacesSubsequentAttrs(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h).WithGroup("req")
+
+	logger.Info("handled", "id", "abc-123")
+
+	events := transport.Events()
+	if got := events[0].Fields["req.id"]; got != "abc-123" {
+		t.Errorf("req.id = %v, want abc-123", got)
+	}
+}
+
+func TestHandler_WithGroup_NestedGroupsAreDottedInOrder(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h).WithGroup("a").WithGroup("b")
+
+	logger.Info("handled", "x", 1)
+
+	events := transport.Events()
+	if got := events[0].Fields["a.b.x"]; got != int64(1) {
+		t.Errorf("a.b.x = %v, want 1", got)
+	}
+}
+
+func TestHandler_WithAttrs_AddsFieldsToEverySubsequentEvent(t *testing.T) {
+	h, transport := newTestHandler(t)
+	logger := slog.New(h).With("request_id", "abc-123")
+
+	logger.Info("first")
+	logger.Info("second")
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for _, e := range events {
+		if got := e.Fields["request_id"]; got != "abc-123" {
+			t.Errorf("request_id = %v, want abc-123 on every event", got)
+		}
+	}
+}
+
+func TestHandler_Enabled_RespectsMinLevel(t *testing.T) {
+	sink, transport := honeylager.NewMemorySink(lager.DEBUG)
+	t.Cleanup(sink.Close)
+
+	logger := slog.New(New(sink, slog.LevelInfo))
+
+	logger.Debug("below min level")
+
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want 0: %+v", len(events), events)
+	}
+
+	logger.Info("at min level")
+	if events := transport.Events(); len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+}
+
+func TestHandler_Handle_UsesContextForTracing(t *testing.T) {
+	sink, transport := honeylager.NewMemorySink(lager.DEBUG)
+	t.Cleanup(sink.Close)
+
+	ctx, done := sink.NewRootSpan(context.Background(), "handle-request")
+	defer done()
+
+	h := New(sink, slog.LevelDebug)
+	slog.New(h).InfoContext(ctx, "handled")
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if _, ok := events[0].Fields["trace.trace_id"]; !ok {
+		t.Error("event is missing trace.trace_id even though it was logged with a context carrying a span")
+	}
+}
@@ -0,0 +1,90 @@
+package honeylager
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// NewStdoutSink returns a Sink that JSON-encodes events to w instead of
+// sending them to Honeycomb, e.g. for local development without a
+// Honeycomb write key.
+func NewStdoutSink(w io.Writer, minLogLevel lager.LogLevel) *Sink {
+	return NewSinkWithTransport(newWriterTransport(w), minLogLevel)
+}
+
+// NewMemorySink returns a Sink backed by a MemoryTransport, which buffers
+// every event sent through it instead of sending it anywhere. This is
+// intended for tests that exercise code which registers a honeylager Sink,
+// without making real Honeycomb calls.
+func NewMemorySink(minLogLevel lager.LogLevel) (*Sink, *MemoryTransport) {
+	transport := newMemoryTransport()
+	return NewSinkWithTransport(transport, minLogLevel), transport
+}
+
+type writerTransport struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newWriterTransport(w io.Writer) *writerTransport {
+	return &writerTransport{w: w}
+}
+
+func (t *writerTransport) Send(e Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return json.NewEncoder(t.w).Encode(e.Fields)
+}
+
+func (t *writerTransport) Responses() <-chan Response {
+	ch := make(chan Response)
+	close(ch)
+	return ch
+}
+
+func (t *writerTransport) Close() error {
+	return nil
+}
+
+// MemoryTransport buffers the Events sent through it, for use in tests that
+// want to assert on what a honeylager Sink logged.
+type MemoryTransport struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func newMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(e Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, e)
+	return nil
+}
+
+// Events returns a copy of the Events sent through this transport so far.
+func (t *MemoryTransport) Events() []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]Event, len(t.events))
+	copy(events, t.events)
+	return events
+}
+
+func (t *MemoryTransport) Responses() <-chan Response {
+	ch := make(chan Response)
+	close(ch)
+	return ch
+}
+
+func (t *MemoryTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,132 @@
+package honeylager
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+)
+
+func TestMemorySink_Log(t *testing.T) {
+	sink, transport := NewMemorySink(lager.INFO)
+	defer sink.Close()
+
+	sink.Log(lager.LogFormat{
+		Source:   "my-component",
+		Message:  "some-action",
+		LogLevel: lager.INFO,
+		Data:     lager.Data{"duration_ms": 12.5},
+	})
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	if got := events[0].Fields["lager_source"]; got != "my-component" {
+		t.Errorf("lager_source = %v, want my-component", got)
+	}
+	if got := events[0].Fields["lager_message"]; got != "some-action" {
+		t.Errorf("lager_message = %v, want some-action", got)
+	}
+	if got := events[0].Fields["duration_ms"]; got != 12.5 {
+		t.Errorf("duration_ms = %v, want 12.5", got)
+	}
+}
+
+func TestMemorySink_Log_BelowMinLevelIsDropped(t *testing.T) {
+	sink, transport := NewMemorySink(lager.ERROR)
+	defer sink.Close()
+
+	sink.Log(lager.LogFormat{Message: "debug-noise", LogLevel: lager.DEBUG})
+
+	if events := transport.Events(); len(events) != 0 {
+		t.Errorf("got %d events, want 0", len(events))
+	}
+}
+
+func TestStdoutSink_Log(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewStdoutSink(&buf, lager.DEBUG)
+	defer sink.Close()
+
+	sink.Log(lager.LogFormat{Message: "some-action", LogLevel: lager.DEBUG})
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &fields); err != nil {
+		t.Fatalf("output wasn't valid JSON: %v (%q)", err, buf.String())
+	}
+
+	if got := fields["lager_message"]; got != "some-action" {
+		t.Errorf("lager_message = %v, want some-action", got)
+	}
+}
+
+func TestSink_With(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	reqSink := sink.With(map[string]interface{}{"request_id": "abc-123"})
+	reqSink.Log(lager.LogFormat{Message: "handled-request", LogLevel: lager.DEBUG})
+	sink.Log(lager.LogFormat{Message: "unrelated", LogLevel: lager.DEBUG})
+
+	events := transport.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if got := events[0].Fields["request_id"]; got != "abc-123" {
+		t.Errorf("request_id = %v, want abc-123", got)
+	}
+	if _, ok := events[1].Fields["request_id"]; ok {
+		t.Errorf("request_id leaked onto the original sink's events: %v", events[1].Fields)
+	}
+}
+
+func TestSink_WithSource(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	sink.WithSource("worker").Log(lager.LogFormat{Message: "tick", LogLevel: lager.DEBUG})
+
+	events := transport.Events()
+	if got := events[0].Fields["source"]; got != "worker" {
+		t.Errorf("source = %v, want worker", got)
+	}
+}
+
+func TestMemorySink_Log_IsConcurrencySafe(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	const n = 50
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			sink.Log(lager.LogFormat{Message: "concurrent", LogLevel: lager.DEBUG})
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+
+	if events := transport.Events(); len(events) != n {
+		t.Errorf("got %d events, want %d", len(events), n)
+	}
+}
+
+func TestStdoutSink_Log_MultipleEventsAreNewlineDelimited(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewStdoutSink(&buf, lager.DEBUG)
+	defer sink.Close()
+
+	sink.Log(lager.LogFormat{Message: "one", LogLevel: lager.DEBUG})
+	sink.Log(lager.LogFormat{Message: "two", LogLevel: lager.DEBUG})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+}
@@ -0,0 +1,175 @@
+package honeylager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type contextKey int
+
+const spanContextKey contextKey = 0
+
+// SpanContext carries the trace/span identifiers threaded through a
+// context.Context, so that related honeylager events can be correlated as a
+// single trace in Honeycomb's trace view.
+type SpanContext struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+}
+
+// ContextWithSpan returns a copy of ctx carrying sc, such that subsequent
+// calls to LogWithContext or NewRootSpan using the returned context pick it
+// up.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey, sc)
+}
+
+// ContextWithTraceParent parses a W3C traceparent header (as received on an
+// inbound request) and returns a copy of ctx carrying the resulting
+// SpanContext, with a freshly minted SpanID for the current unit of work
+// and the incoming span recorded as its parent.
+func ContextWithTraceParent(ctx context.Context, header string) (context.Context, error) {
+	sc, err := ParseTraceParent(header)
+	if err != nil {
+		return ctx, err
+	}
+
+	return ContextWithSpan(ctx, sc), nil
+}
+
+// SpanFromContext extracts a SpanContext from ctx. It first looks for one
+// previously stored via ContextWithSpan, ContextWithTraceParent or
+// NewRootSpan, then falls back to the OpenTelemetry SpanContext (if any)
+// carried by ctx, so that services already instrumented with OTel get
+// Honeycomb trace correlation without having to call into honeylager
+// explicitly. ok is false if ctx carries neither.
+func SpanFromContext(ctx context.Context) (sc SpanContext, ok bool) {
+	if sc, ok := ctx.Value(spanContextKey).(SpanContext); ok {
+		return sc, true
+	}
+
+	if otelSC := oteltrace.SpanContextFromContext(ctx); otelSC.IsValid() {
+		return SpanContext{
+			TraceID: otelSC.TraceID().String(),
+			SpanID:  otelSC.SpanID().String(),
+		}, true
+	}
+
+	return SpanContext{}, false
+}
+
+// ParseTraceParent parses a W3C traceparent header value, e.g.
+// "00-<32 hex trace id>-<16 hex span id>-<flags>", into a SpanContext for
+// the current unit of work: the incoming span becomes the parent, and a
+// fresh span id is minted, so that each hop forms its own link in the
+// trace rather than all hops reusing the same span id.
+func ParseTraceParent(header string) (SpanContext, error) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent header: %q", header)
+	}
+
+	return SpanContext{
+		TraceID:  parts[1],
+		ParentID: parts[2],
+		SpanID:   newSpanID(),
+	}, nil
+}
+
+// LogWithContext behaves like Log, additionally tagging the event with
+// trace.trace_id, trace.span_id and trace.parent_id fields -- Honeycomb's
+// well-known field names for correlating events into a trace -- derived
+// from the SpanContext (if any) carried by ctx.
+func (sink *Sink) LogWithContext(ctx context.Context, logFormat lager.LogFormat) {
+	if sc, ok := SpanFromContext(ctx); ok {
+		if logFormat.Data == nil {
+			logFormat.Data = lager.Data{}
+		}
+
+		logFormat.Data["trace.trace_id"] = sc.TraceID
+		logFormat.Data["trace.span_id"] = sc.SpanID
+		if sc.ParentID != "" {
+			logFormat.Data["trace.parent_id"] = sc.ParentID
+		}
+	}
+
+	sink.Log(logFormat)
+}
+
+// NewRootSpan allocates a new span id -- generating a trace id too, unless
+// ctx already carries one -- and stashes it in the returned context. The
+// returned closer emits a span-completion event carrying a duration_ms
+// field computed from the time NewRootSpan was called; callers will
+// typically defer it:
+//
+//	ctx, done := sink.NewRootSpan(ctx, "handle-request")
+//	defer done()
+func (sink *Sink) NewRootSpan(ctx context.Context, name string) (context.Context, func()) {
+	parent, _ := SpanFromContext(ctx)
+
+	sc := SpanContext{
+		TraceID:  parent.TraceID,
+		SpanID:   newSpanID(),
+		ParentID: parent.SpanID,
+	}
+	if sc.TraceID == "" {
+		sc.TraceID = newSpanID()
+	}
+
+	start := time.Now()
+	spanCtx := ContextWithSpan(ctx, sc)
+
+	return spanCtx, func() {
+		sink.LogWithContext(spanCtx, lager.LogFormat{
+			Source:    name,
+			Message:   name + ".span-complete",
+			LogLevel:  lager.INFO,
+			Timestamp: formatLagerTimestamp(time.Now()),
+			Data: lager.Data{
+				"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+			},
+		})
+	}
+}
+
+// TraceSink adapts a Sink bound to a fixed context into the plain
+// lager.Sink interface (Log(LogFormat) only), since that's what
+// lager.Logger.RegisterSink requires and LogWithContext can't be passed to
+// it directly. Use NewTraceSink to construct one.
+type TraceSink struct {
+	sink *Sink
+	ctx  context.Context
+}
+
+// NewTraceSink returns a lager.Sink that logs through sink via
+// LogWithContext(ctx, ...), so every event registered through it carries
+// ctx's trace/span fields:
+//
+//	l := lager.NewLogger("my-component")
+//	l.RegisterSink(sink.NewTraceSink(ctx))
+func (sink *Sink) NewTraceSink(ctx context.Context) *TraceSink {
+	return &TraceSink{sink: sink, ctx: ctx}
+}
+
+// Log implements lager.Sink.
+func (t *TraceSink) Log(logFormat lager.LogFormat) {
+	t.sink.LogWithContext(t.ctx, logFormat)
+}
+
+func newSpanID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func formatLagerTimestamp(t time.Time) string {
+	return fmt.Sprintf("%.9f", float64(t.UnixNano())/1e9)
+}
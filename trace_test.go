@@ -0,0 +1,130 @@
+package honeylager
+
+import (
+	"context"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceParent(header)
+	if err != nil {
+		t.Fatalf("ParseTraceParent returned an error: %v", err)
+	}
+
+	if sc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %q, want the trace id from the header", sc.TraceID)
+	}
+	if sc.ParentID != "00f067aa0ba902b7" {
+		t.Errorf("ParentID = %q, want the incoming span id from the header", sc.ParentID)
+	}
+	if sc.SpanID == "" || sc.SpanID == sc.ParentID {
+		t.Errorf("SpanID = %q, want a freshly minted span id distinct from ParentID", sc.SpanID)
+	}
+}
+
+func TestParseTraceParent_InvalidHeader(t *testing.T) {
+	if _, err := ParseTraceParent("not-a-valid-header-at-all"); err == nil {
+		t.Fatal("expected an error for a malformed traceparent header, got nil")
+	}
+}
+
+func TestSpanFromContext_FallsBackToOTelSpanContext(t *testing.T) {
+	traceID, err := oteltrace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatalf("failed to build a test trace id: %v", err)
+	}
+	spanID, err := oteltrace.SpanIDFromHex("00f067aa0ba902b7")
+	if err != nil {
+		t.Fatalf("failed to build a test span id: %v", err)
+	}
+
+	otelSC := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), otelSC)
+
+	sc, ok := SpanFromContext(ctx)
+	if !ok {
+		t.Fatal("SpanFromContext returned ok=false for a context carrying a valid OTel SpanContext")
+	}
+	if sc.TraceID != traceID.String() {
+		t.Errorf("TraceID = %q, want %q", sc.TraceID, traceID.String())
+	}
+	if sc.SpanID != spanID.String() {
+		t.Errorf("SpanID = %q, want %q", sc.SpanID, spanID.String())
+	}
+}
+
+func TestSpanFromContext_PreviouslyStoredSpanTakesPrecedenceOverOTel(t *testing.T) {
+	traceID, _ := oteltrace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := oteltrace.SpanIDFromHex("00f067aa0ba902b7")
+	otelSC := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), otelSC)
+
+	ctx = ContextWithSpan(ctx, SpanContext{TraceID: "stored-trace", SpanID: "stored-span"})
+
+	sc, ok := SpanFromContext(ctx)
+	if !ok {
+		t.Fatal("SpanFromContext returned ok=false")
+	}
+	if sc.TraceID != "stored-trace" || sc.SpanID != "stored-span" {
+		t.Errorf("got %+v, want the explicitly stored SpanContext to take precedence over the OTel one", sc)
+	}
+}
+
+func TestSpanFromContext_NoSpanPresent(t *testing.T) {
+	if _, ok := SpanFromContext(context.Background()); ok {
+		t.Error("SpanFromContext returned ok=true for a context carrying no span")
+	}
+}
+
+func TestSink_NewRootSpan(t *testing.T) {
+	sink, transport := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	ctx, done := sink.NewRootSpan(context.Background(), "handle-request")
+
+	sc, ok := SpanFromContext(ctx)
+	if !ok {
+		t.Fatal("NewRootSpan did not stash a SpanContext in the returned context")
+	}
+	if sc.TraceID == "" || sc.SpanID == "" {
+		t.Errorf("got %+v, want both TraceID and SpanID populated", sc)
+	}
+
+	done()
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1 span-complete event", len(events))
+	}
+	if got := events[0].Fields["trace.span_id"]; got != sc.SpanID {
+		t.Errorf("trace.span_id = %v, want %v", got, sc.SpanID)
+	}
+	if _, ok := events[0].Fields["duration_ms"]; !ok {
+		t.Error("span-complete event is missing duration_ms")
+	}
+}
+
+func TestSink_NewRootSpan_NestedSpanRecordsParent(t *testing.T) {
+	sink, _ := NewMemorySink(lager.DEBUG)
+	defer sink.Close()
+
+	rootCtx, rootDone := sink.NewRootSpan(context.Background(), "outer")
+	defer rootDone()
+	rootSC, _ := SpanFromContext(rootCtx)
+
+	childCtx, childDone := sink.NewRootSpan(rootCtx, "inner")
+	defer childDone()
+	childSC, _ := SpanFromContext(childCtx)
+
+	if childSC.TraceID != rootSC.TraceID {
+		t.Errorf("child TraceID = %q, want it to match the root span's TraceID %q", childSC.TraceID, rootSC.TraceID)
+	}
+	if childSC.ParentID != rootSC.SpanID {
+		t.Errorf("child ParentID = %q, want the root span's SpanID %q", childSC.ParentID, rootSC.SpanID)
+	}
+}
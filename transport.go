@@ -0,0 +1,89 @@
+package honeylager
+
+import (
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+)
+
+// Event is the transport-agnostic representation of a single log event,
+// built up by Sink.Log from a lager.LogFormat.
+type Event struct {
+	Fields     map[string]interface{}
+	Timestamp  time.Time
+	Metadata   interface{}
+	SampleRate uint
+}
+
+// Response describes the outcome of sending an Event.
+type Response struct {
+	Metadata   interface{}
+	StatusCode int
+	Err        error
+	Body       []byte
+	Duration   time.Duration
+}
+
+// Transport sends Events to a destination and reports the outcome of doing
+// so via Responses(). Implementations must be safe for concurrent use.
+//
+// NewSink uses a libhoney-backed Transport by default. NewStdoutSink and
+// NewMemorySink provide Transports that let code which registers a
+// honeylager Sink be unit-tested without making real Honeycomb calls.
+type Transport interface {
+	Send(Event) error
+	Responses() <-chan Response
+	Close() error
+}
+
+// libhoneyTransport is the default Transport, sending events to Honeycomb
+// via libhoney.
+type libhoneyTransport struct {
+	builder *libhoney.Builder
+}
+
+func newLibhoneyTransport(honeycombWriteKey, honeycombDataset string) *libhoneyTransport {
+	b := libhoney.NewBuilder()
+	b.WriteKey = honeycombWriteKey
+	b.Dataset = honeycombDataset
+
+	return &libhoneyTransport{builder: b}
+}
+
+func (t *libhoneyTransport) Send(e Event) error {
+	ev := t.builder.NewEvent()
+	ev.Add(e.Fields)
+	ev.Metadata = e.Metadata
+	if !e.Timestamp.IsZero() {
+		ev.Timestamp = e.Timestamp
+	}
+	if e.SampleRate > 0 {
+		ev.SampleRate = e.SampleRate
+	}
+
+	return ev.Send()
+}
+
+func (t *libhoneyTransport) Responses() <-chan Response {
+	out := make(chan Response)
+
+	go func() {
+		defer close(out)
+		for r := range libhoney.Responses() {
+			out <- Response{
+				Metadata:   r.Metadata,
+				StatusCode: r.StatusCode,
+				Err:        r.Err,
+				Body:       r.Body,
+				Duration:   r.Duration,
+			}
+		}
+	}()
+
+	return out
+}
+
+func (t *libhoneyTransport) Close() error {
+	libhoney.Close()
+	return nil
+}